@@ -0,0 +1,98 @@
+// Package alarm implements a small cluster-wide alarm subsystem,
+// borrowed from etcdserver's alarm package: any node can raise a named
+// alarm, it is persisted under a well-known etcd path so every node
+// (and the controller) can observe it, and the framework reacts to
+// certain alarm classes by refusing to make progress until the alarm
+// is disarmed.
+package alarm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/go-distributed/meritop/pkg/etcdutil"
+)
+
+// Well-known alarm names.
+const (
+	// NoSpace is raised when a Store returns ENOSPC from Sync.
+	NoSpace = "NoSpace"
+	// EpochStalled is raised when the epoch hasn't advanced within a
+	// configurable window.
+	EpochStalled = "EpochStalled"
+	// MemberLost is raised when a task's slot lease expires and can't be
+	// reclaimed within its TTL.
+	MemberLost = "MemberLost"
+)
+
+// rootPath is the well-known etcd directory alarms for job are kept
+// under, so any node or the controller can list them without needing
+// to know who raised them.
+func rootPath(job string) string {
+	return fmt.Sprintf("/%s/alarms", job)
+}
+
+func keyPath(job, name string) string {
+	return fmt.Sprintf("%s/%s", rootPath(job), name)
+}
+
+// Alarm is a single raised, named alarm with arbitrary metadata
+// attached, e.g. which task raised it, or the offending epoch.
+type Alarm struct {
+	Name string            `json:"name"`
+	Meta map[string]string `json:"meta"`
+}
+
+// Raise persists name as active for job, with the given metadata.
+// Raising an already-active alarm just refreshes its metadata.
+func Raise(client *etcd.Client, job, name string, meta map[string]string) error {
+	data, err := json.Marshal(Alarm{Name: name, Meta: meta})
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(keyPath(job, name), string(data), 0)
+	return err
+}
+
+// Disarm clears a previously raised alarm. Disarming an alarm that
+// isn't active is not an error.
+func Disarm(client *etcd.Client, job, name string) error {
+	_, err := client.Delete(keyPath(job, name), false)
+	if err != nil && etcdutil.IsKeyNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Active reports whether name is currently raised for job.
+func Active(client *etcd.Client, job, name string) (bool, error) {
+	_, err := client.Get(keyPath(job, name), false, false)
+	if err != nil {
+		if etcdutil.IsKeyNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// List returns every alarm currently active for job.
+func List(client *etcd.Client, job string) ([]Alarm, error) {
+	resp, err := client.Get(rootPath(job), true, false)
+	if err != nil {
+		if etcdutil.IsKeyNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	alarms := make([]Alarm, 0, len(resp.Node.Nodes))
+	for _, n := range resp.Node.Nodes {
+		var a Alarm
+		if err := json.Unmarshal([]byte(n.Value), &a); err != nil {
+			return nil, err
+		}
+		alarms = append(alarms, a)
+	}
+	return alarms, nil
+}
@@ -0,0 +1,22 @@
+package alarm
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// IsNoSpace reports whether err, as returned by a store.Store's Sync,
+// indicates the underlying device or service is out of space. Only
+// LocalStore surfaces a raw syscall.ENOSPC; remote stores are expected
+// to wrap their own "out of space" response the same way.
+func IsNoSpace(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err == syscall.ENOSPC
+	}
+	return errors.Is(err, syscall.ENOSPC)
+}
@@ -0,0 +1,16 @@
+package controller
+
+import "github.com/go-distributed/meritop/alarm"
+
+// ListAlarms returns every alarm currently raised for this job, so an
+// operator or monitoring task can see what's wrong without talking to
+// etcd directly.
+func (c *Controller) ListAlarms() ([]alarm.Alarm, error) {
+	return alarm.List(c.etcdClient, c.name)
+}
+
+// DisarmAlarm clears a previously raised alarm by name. Disarming an
+// alarm that isn't currently active is not an error.
+func (c *Controller) DisarmAlarm(name string) error {
+	return alarm.Disarm(c.etcdClient, c.name, name)
+}
@@ -0,0 +1,97 @@
+package framework
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/go-distributed/meritop/alarm"
+)
+
+// defaultEpochStallWindow is how long the epoch can go unchanged before
+// the framework raises alarm.EpochStalled, unless overridden with
+// WithEpochStallWindow.
+const defaultEpochStallWindow = 5 * time.Minute
+
+// WithEpochStallWindow sets how long the epoch may go without changing
+// before the framework raises alarm.EpochStalled.
+func WithEpochStallWindow(d time.Duration) BootStrapOption {
+	return func(f *framework) { f.epochStallWindow = d }
+}
+
+// watchAlarms watches the well-known alarm directory for this job and
+// surfaces every raise/clear to the task via Task.AlarmRaised, so a
+// task can react (or just log) without polling etcd itself.
+func (f *framework) watchAlarms() {
+	receiver := make(chan *etcd.Response, 10)
+	stop := make(chan bool, 1)
+	f.stops = append(f.stops, stop)
+
+	go f.etcdClient.Watch(alarmWatchPath(f.name), 0, true, receiver, stop)
+	for resp := range receiver {
+		name := resp.Node.Key[len(alarmWatchPath(f.name))+1:]
+		switch resp.Action {
+		case "delete", "expire":
+			f.task.AlarmRaised(name, map[string]string{"cleared": "true"})
+		default:
+			var a alarm.Alarm
+			if err := json.Unmarshal([]byte(resp.Node.Value), &a); err != nil {
+				f.log.Printf("WARN: malformed %s alarm: %v", name, err)
+				continue
+			}
+			f.task.AlarmRaised(name, a.Meta)
+		}
+	}
+}
+
+func alarmWatchPath(job string) string {
+	return "/" + job + "/alarms"
+}
+
+// watchEpochStall raises alarm.EpochStalled if the epoch goes more than
+// f.epochStallWindow without changing, and disarms it as soon as the
+// epoch moves again.
+func (f *framework) watchEpochStall() {
+	window := f.epochStallWindow
+	if window == 0 {
+		window = defaultEpochStallWindow
+	}
+
+	ticker := time.NewTicker(window / 5)
+	defer ticker.Stop()
+
+	lastEpoch := f.getEpoch()
+	lastChange := time.Now()
+	stalled := false
+	for range ticker.C {
+		if epoch := f.getEpoch(); epoch != lastEpoch {
+			lastEpoch = epoch
+			lastChange = time.Now()
+			if stalled {
+				alarm.Disarm(f.etcdClient, f.name, alarm.EpochStalled)
+				stalled = false
+			}
+			continue
+		}
+		if !stalled && time.Since(lastChange) >= window {
+			alarm.Raise(f.etcdClient, f.name, alarm.EpochStalled, map[string]string{
+				"epoch": strconv.FormatUint(lastEpoch, 10),
+			})
+			stalled = true
+		}
+	}
+}
+
+// epochBlocked reports whether a NoSpace-class alarm is currently
+// active for this job, in which case the framework refuses to advance
+// the epoch it hands to the task, matching how etcd itself rejects
+// writes while alarms are set.
+func (f *framework) epochBlocked() bool {
+	active, err := alarm.Active(f.etcdClient, f.name, alarm.NoSpace)
+	if err != nil {
+		f.log.Printf("WARN: could not check %s alarm: %v", alarm.NoSpace, err)
+		return false
+	}
+	return active
+}
@@ -0,0 +1,126 @@
+package framework
+
+import (
+	"time"
+
+	"github.com/go-distributed/meritop"
+	"github.com/go-distributed/meritop/alarm"
+	"github.com/go-distributed/meritop/store"
+	"github.com/go-distributed/meritop/wal"
+)
+
+// WithBackup turns on WAL-backed durability for tasks that implement
+// both meritop.Backupable and wal.Snapshotter: updates are appended to
+// a log under dir before being applied, and periodically snapshotted
+// to s so the log can be truncated. See the wal package.
+func WithBackup(dir string, s store.Store) BootStrapOption {
+	return func(f *framework) {
+		f.backupDir = dir
+		f.backupStore = s
+	}
+}
+
+// recoverBackup is a no-op unless both WithBackup was passed to
+// NewBootStrap and the task implements meritop.Backupable and
+// wal.Snapshotter. When it applies, it loads the latest snapshot and
+// replays the WAL tail through task.Update before the task starts
+// serving traffic, then opens the WAL and snapshot manager the task
+// will append future updates through.
+func (f *framework) recoverBackup() {
+	if f.backupStore == nil {
+		return
+	}
+	backup, ok := f.task.(meritop.Backupable)
+	if !ok {
+		return
+	}
+	if _, ok := f.task.(wal.Snapshotter); !ok {
+		return
+	}
+
+	mgr := wal.NewManager(f.backupDir, f.backupStore, f.name)
+	_, lastIndex, err := mgr.Recover(func(rec wal.Record) {
+		backup.Update(backupUpdateLog{index: rec.Index, data: rec.Data})
+	})
+	if err != nil {
+		f.log.Fatalf("backup recovery failed: %v", err)
+	}
+
+	// wal.Open (not Create) so a restart resumes appending into the
+	// segment replay just read from, instead of rolling a fresh
+	// "...0000.wal" and breaking in-order replay on the next restart.
+	w, err := wal.Open(f.backupDir)
+	if err != nil {
+		f.log.Fatalf("backup WAL open failed: %v", err)
+	}
+	f.backupWAL = w
+	f.backupMgr = mgr
+	f.backupIndex = lastIndex
+}
+
+// UpdateBackup durably records a primary task's state change: it
+// appends data to the backup WAL (triggering a snapshot once the
+// manager's thresholds are hit), then applies it locally through the
+// same Backupable.Update hook a recovering or promoted backup replays
+// through. A task that implements Backupable calls this, via its
+// Framework, every time it would otherwise call Update on itself
+// directly, so the two copies never drift apart.
+func (f *framework) UpdateBackup(data []byte) error {
+	if f.backupWAL == nil {
+		return nil
+	}
+	backup, ok := f.task.(meritop.Backupable)
+	if !ok {
+		return nil
+	}
+
+	f.backupIndex++
+	if err := f.appendBackup(f.backupIndex, data); err != nil {
+		return err
+	}
+	backup.Update(backupUpdateLog{index: f.backupIndex, data: data})
+	return nil
+}
+
+// appendBackup durably records data at index in the backup WAL, then
+// triggers a snapshot once the manager's thresholds are hit. If the
+// underlying disk or store is out of space, it raises alarm.NoSpace
+// rather than silently dropping the update.
+func (f *framework) appendBackup(index uint64, data []byte) error {
+	if f.backupWAL == nil {
+		return nil
+	}
+	if err := f.backupWAL.Append(data, index); err != nil {
+		f.reportIfNoSpace(err)
+		return err
+	}
+	if err := f.backupWAL.Sync(); err != nil {
+		f.reportIfNoSpace(err)
+		return err
+	}
+	if snap, ok := f.task.(wal.Snapshotter); ok {
+		// UpdateBackup applies index to the task only after this returns,
+		// so a snapshot taken here reflects the records up to index-1.
+		if err := f.backupMgr.Observe(snap, index-1, time.Now()); err != nil {
+			f.reportIfNoSpace(err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *framework) reportIfNoSpace(err error) {
+	if alarm.IsNoSpace(err) {
+		alarm.Raise(f.etcdClient, f.name, alarm.NoSpace, nil)
+	}
+}
+
+// backupUpdateLog adapts a replayed wal.Record to meritop.UpdateLog so
+// it can be delivered through the same Update hook that live updates
+// use.
+type backupUpdateLog struct {
+	index uint64
+	data  []byte
+}
+
+func (b backupUpdateLog) UpdateID() {}
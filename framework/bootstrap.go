@@ -1,16 +1,21 @@
 package framework
 
 import (
+	"expvar"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"net/http"
 	"os"
 	"path"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/coreos/go-etcd/etcd"
 	"github.com/go-distributed/meritop"
+	"github.com/go-distributed/meritop/alarm"
 	"github.com/go-distributed/meritop/framework/frameworkhttp"
 	"github.com/go-distributed/meritop/pkg/etcdutil"
 )
@@ -23,14 +28,43 @@ const (
 	roleChild
 )
 
+// defaultLeaseTTL is how long a task occupancy slot survives without a
+// refresh, used unless the bootstrap is given WithLeaseTTL.
+const defaultLeaseTTL = 10 * time.Second
+
+// maxLeaseRefreshFailures is how many consecutive lease refresh errors
+// we tolerate before assuming the lease is lost and demoting to standby.
+const maxLeaseRefreshFailures = 3
+
+// epochBlockedRetryInterval is how often Start retries delivering an
+// epoch that a NoSpace alarm held back, once that alarm is no longer
+// active.
+const epochBlockedRetryInterval = time.Second
+
+// BootStrapOption configures optional framework behavior before Start.
+type BootStrapOption func(*framework)
+
+// WithLeaseTTL sets the TTL on the etcd key that backs this node's task
+// occupancy. Occupancy becomes self-expiring: if the node crashes, its
+// slot frees up after exactly one TTL instead of needing a separate
+// heartbeat/failure-detection heuristic to notice.
+func WithLeaseTTL(ttl time.Duration) BootStrapOption {
+	return func(f *framework) { f.leaseTTL = ttl }
+}
+
 // One need to pass in at least these two for framework to start.
-func NewBootStrap(jobName string, etcdURLs []string, ln net.Listener, logger *log.Logger) meritop.Bootstrap {
-	return &framework{
+func NewBootStrap(jobName string, etcdURLs []string, ln net.Listener, logger *log.Logger, opts ...BootStrapOption) meritop.Bootstrap {
+	f := &framework{
 		name:     jobName,
 		etcdURLs: etcdURLs,
 		ln:       ln,
 		log:      logger,
+		leaseTTL: defaultLeaseTTL,
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 func (f *framework) SetTaskBuilder(taskBuilder meritop.TaskBuilder) { f.taskBuilder = taskBuilder }
@@ -40,6 +74,10 @@ func (f *framework) SetTopology(topology meritop.Topology) { f.topology = topolo
 func (f *framework) Start() {
 	var err error
 
+	if f.transport == nil {
+		f.transport = NewHTTPTransport(f.ln)
+	}
+
 	if f.log == nil {
 		f.log = log.New(os.Stdout, "", log.Lshortfile|log.Ltime|log.Ldate)
 	}
@@ -48,6 +86,7 @@ func (f *framework) Start() {
 
 	if f.taskID, err = f.occupyTask(); err != nil {
 		// if err == full
+		recordStandby(f.taskID)
 		if err := f.standby(); err != nil {
 			f.log.Fatalf("occupyTask failed: %v", err)
 		}
@@ -62,6 +101,7 @@ func (f *framework) Start() {
 	// For example, if a watch of parent meta is triggered but task isn't init-ed
 	// yet, then there will a null pointer access
 	f.task.Init(f.taskID, f)
+	f.recoverBackup()
 
 	// First, we fetch the current global epoch from etcd.
 	f.epochChan = make(chan uint64, 1)
@@ -70,8 +110,8 @@ func (f *framework) Start() {
 		f.log.Fatalf("WatchEpoch failed: %v", err)
 	}
 
-	go f.heartbeat()
-	go f.detectAndReportFailures()
+	go f.watchAlarms()
+	go f.watchEpochStall()
 
 	// setup etcd watches
 	// - create self's parent and child meta flag
@@ -81,33 +121,138 @@ func (f *framework) Start() {
 	f.watchAll(roleChild, f.topology.GetChildren(f.epoch))
 
 	go f.startHTTP()
-	f.dataRespChan = make(chan *frameworkhttp.DataResponse, 100)
-	go f.dataResponseReceiver()
 
+	// defers run LIFO, so declare them in the reverse of the order they
+	// should execute: drain in-flight /datareq requests first, then let
+	// the occupancy lease go, then release everything else, and only
+	// after all of that, if keepAliveLease demoted this node, go to
+	// standby.
+	demoted := false
+	defer func() {
+		if !demoted {
+			return
+		}
+		recordStandby(f.taskID)
+		if err := f.standby(); err != nil {
+			f.log.Fatalf("standby failed: %v", err)
+		}
+	}()
 	defer f.releaseResources()
+	defer f.stopLease()
+	defer f.stopTransport()
 	f.log.Printf("Start the work, task: %d\n", f.taskID)
+	recordEpoch(f.taskID, f.epoch)
 	f.task.SetEpoch(f.epoch)
-	for f.epoch = range f.epochChan {
-		f.task.SetEpoch(f.epoch)
+
+	// blockedEpoch holds the most recent epoch a NoSpace alarm held
+	// back, so it can be redelivered once the alarm clears instead of
+	// being dropped for good: nothing else re-publishes a skipped epoch,
+	// and epoch advancement is gated on this task reporting progress at
+	// it, so losing one here would wedge the job until a later epoch
+	// happened to come in some other way.
+	var blockedEpoch uint64
+	haveBlocked := false
+
+	retry := time.NewTicker(epochBlockedRetryInterval)
+	defer retry.Stop()
+
+	for {
+		select {
+		case epoch, ok := <-f.epochChan:
+			if !ok {
+				return
+			}
+			f.setEpoch(epoch)
+			if f.epochBlocked() {
+				f.log.Printf("epoch advance to %d held back: %s alarm is active", epoch, alarm.NoSpace)
+				blockedEpoch, haveBlocked = epoch, true
+				continue
+			}
+			haveBlocked = false
+			recordEpoch(f.taskID, epoch)
+			f.task.SetEpoch(epoch)
+		case <-retry.C:
+			if !haveBlocked || f.epochBlocked() {
+				continue
+			}
+			f.log.Printf("%s alarm cleared, redelivering held-back epoch %d", alarm.NoSpace, blockedEpoch)
+			haveBlocked = false
+			recordEpoch(f.taskID, blockedEpoch)
+			f.task.SetEpoch(blockedEpoch)
+		case <-f.demoted:
+			// keepAliveLease lost the lease and closed f.demoted instead
+			// of acting as task owner itself: returning here runs the
+			// deferred stopTransport/stopLease/releaseResources above,
+			// which stop /datareq, watchAll's callbacks, and the etcd
+			// watches before the first-declared defer calls standby.
+			// Without that ordering the node would keep serving as the
+			// owner of a slot another node is about to reclaim.
+			f.log.Printf("task %d demoted, tearing down", f.taskID)
+			demoted = true
+			return
+		}
 	}
 }
 
-// Framework http server for data request.
+// Framework data transport server for data request.
 // Each request will be in the format: "/datareq?taskID=XXX&req=XXX".
 // "taskID" indicates the requesting task. "req" is the meta data for this request.
-// On success, it should respond with requested data in http body.
+// On success, it should respond with requested data in the response body.
+// f.transport.Serve blocks until stopTransport calls Close, at which point
+// the resulting nil/ErrServerClosed-derived error is expected, not fatal.
 func (f *framework) startHTTP() {
-	f.log.Printf("serving http on %s", f.ln.Addr())
-	// TODO: http server graceful shutdown
+	f.log.Printf("serving data transport on %s", f.ln.Addr())
 	epocher := frameworkhttp.Epocher(f)
 	handler := frameworkhttp.NewDataRequestHandler(f.topology, f.task, epocher)
-	if err := http.Serve(f.ln, handler); err != nil {
-		f.log.Fatalf("http.Serve() returns error: %v\n", err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/", f.instrumentDataReq(handler))
+
+	if err := f.transport.Serve(mux); err != nil {
+		f.log.Fatalf("transport.Serve() returned error: %v\n", err)
+	}
+}
+
+// instrumentDataReq wraps handler to record taskgraph_data_requests_total
+// and taskgraph_data_request_latency_seconds for every /datareq call.
+func (f *framework) instrumentDataReq(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(rec, r)
+		recordDataRequest(f.taskID, start, rec.status < http.StatusBadRequest)
+	})
+}
+
+// statusRecorder captures the status code an http.Handler wrote, since
+// http.ResponseWriter doesn't expose it directly.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// stopTransport gracefully shuts the data transport down, giving
+// in-flight /datareq requests up to f.shutdownGrace to finish.
+func (f *framework) stopTransport() {
+	if err := f.transport.Close(f.shutdownGrace); err != nil {
+		f.log.Printf("WARN: transport shutdown: %v", err)
 	}
 }
 
-// occupyTask will grab the first unassigned task and register itself on etcd.
+// occupyTask will grab the first unassigned task and register itself on
+// etcd as a TTL lease rather than a plain key: the slot expires after
+// f.leaseTTL unless keepAliveLease refreshes it, so a crashed node's
+// slot becomes available again on its own, with no separate heartbeat
+// or failure-detection heuristic needed to notice and reclaim it.
 func (f *framework) occupyTask() (uint64, error) {
+	recordOccupyAttempt()
+
 	// get all nodes under task dir
 	slots, err := f.etcdClient.Get(etcdutil.MakeTaskDirPath(f.name), true, true)
 	if err != nil {
@@ -120,14 +265,95 @@ func (f *framework) occupyTask() (uint64, error) {
 			f.log.Printf("WARN: taskID isn't integer, registration on etcd has been corrupted!")
 			continue
 		}
-		ok := etcdutil.TryOccupyTask(f.etcdClient, f.name, id, f.ln.Addr().String())
+		ok := etcdutil.TryOccupyTaskWithTTL(f.etcdClient, f.name, id, f.ln.Addr().String(), f.leaseTTL)
 		if ok {
+			recordOccupySuccess()
+			f.leaseStop = make(chan struct{})
+			f.demoted = make(chan struct{})
+			go f.keepAliveLease(id)
 			return id, nil
 		}
 	}
 	return 0, fmt.Errorf("no unassigned task found")
 }
 
+// keepAliveLease refreshes this node's task-occupancy lease at roughly
+// TTL/3, with jittered backoff on transient etcd errors. After
+// maxLeaseRefreshFailures consecutive failures it demotes the node to
+// standby rather than fatally exiting, the way etcd itself handles
+// session loss rather than crashing the whole cluster.
+func (f *framework) keepAliveLease(taskID uint64) {
+	interval := f.leaseTTL / 3
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-f.leaseStop:
+			return
+		case <-timer.C:
+			ok := etcdutil.TryOccupyTaskWithTTL(f.etcdClient, f.name, taskID, f.ln.Addr().String(), f.leaseTTL)
+			if ok {
+				failures = 0
+				timer.Reset(interval)
+				continue
+			}
+			failures++
+			recordLeaseRefreshFailure(taskID)
+			f.log.Printf("WARN: lease refresh for task %d failed (%d/%d)", taskID, failures, maxLeaseRefreshFailures)
+			if failures >= maxLeaseRefreshFailures {
+				f.log.Printf("task %d lost its lease, demoting to standby", taskID)
+				alarm.Raise(f.etcdClient, f.name, alarm.MemberLost, map[string]string{
+					"taskID": strconv.FormatUint(taskID, 10),
+				})
+				// Close demoted rather than calling f.standby() here:
+				// this goroutine losing the lease doesn't stop Start's
+				// epoch loop, watchAll callbacks, or the data transport
+				// from continuing to act as task owner. Closing the
+				// channel lets Start tear all of that down first, then
+				// call f.standby() itself once nothing is left serving
+				// on the taskID we just conceded.
+				close(f.demoted)
+				return
+			}
+			timer.Reset(jitter(interval))
+		}
+	}
+}
+
+// stopLease stops keepAliveLease, letting this node's occupancy lease
+// expire. It is safe to call more than once and safe to call when no
+// lease goroutine was ever started.
+func (f *framework) stopLease() {
+	if f.leaseStop == nil {
+		return
+	}
+	select {
+	case <-f.leaseStop:
+		// already stopped
+	default:
+		close(f.leaseStop)
+	}
+}
+
+// jitter returns a duration in [d/2, 3d/2), so a fleet of nodes whose
+// leases were created around the same time don't all retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// setEpoch and getEpoch give f.epoch atomic access: the main Start loop
+// writes it on every epoch advance while watchEpochStall polls it from
+// its own goroutine, so a plain read/write would race.
+func (f *framework) setEpoch(epoch uint64) {
+	atomic.StoreUint64(&f.epoch, epoch)
+}
+
+func (f *framework) getEpoch() uint64 {
+	return atomic.LoadUint64(&f.epoch)
+}
+
 func (f *framework) watchAll(who taskRole, taskIDs []uint64) {
 	stops := make([]chan bool, len(taskIDs))
 
@@ -138,15 +364,18 @@ func (f *framework) watchAll(who taskRole, taskIDs []uint64) {
 
 		var watchPath string
 		var taskCallback func(uint64, string)
+		var dataCallback func(uint64, string, []byte)
 		switch who {
 		case roleParent:
 			// Watch parent's child.
 			watchPath = etcdutil.MakeChildMetaPath(f.name, taskID)
 			taskCallback = f.task.ParentMetaReady
+			dataCallback = f.task.ParentDataReady
 		case roleChild:
 			// Watch child's parent.
 			watchPath = etcdutil.MakeParentMetaPath(f.name, taskID)
 			taskCallback = f.task.ChildMetaReady
+			dataCallback = f.task.ChildDataReady
 		default:
 			panic("unimplemented")
 		}
@@ -172,14 +401,18 @@ func (f *framework) watchAll(who taskRole, taskIDs []uint64) {
 		go func(receiver <-chan *etcd.Response, taskID uint64) {
 			if resp != nil {
 				f.log.Println("hehe task:", f.taskID, "path:", watchPath, resp.Node.Value)
+				recordMetaEvent(f.taskID, who)
 				taskCallback(taskID, resp.Node.Value)
+				f.requestData(taskID, resp.Node.Value, dataCallback)
 			}
 			for resp := range receiver {
 				if resp.Action != "set" {
 					continue
 				}
 				f.log.Println("task:", f.taskID, "path:", watchPath, resp.Node.Value)
+				recordMetaEvent(f.taskID, who)
 				taskCallback(taskID, resp.Node.Value)
+				f.requestData(taskID, resp.Node.Value, dataCallback)
 			}
 		}(receiver, taskID)
 	}
@@ -0,0 +1,45 @@
+package framework
+
+import (
+	"context"
+	"path"
+	"strconv"
+
+	"github.com/go-distributed/meritop/pkg/etcdutil"
+)
+
+// requestData fetches req from taskID over f.transport - the same
+// DataTransport Serve uses for the inbound /datareq handler, so picking
+// a non-default transport (e.g. grpctransport, for mTLS) changes both
+// directions at once instead of just the inbound server. It replaces
+// the old path of waiting on a frameworkhttp.DataResponse delivered
+// through a dedicated channel/receiver goroutine, which never went
+// through DataTransport at all. deliver is called with the response
+// once it arrives; failures are logged and otherwise swallowed, the
+// same way the rest of watchAll's callbacks treat a single bad peer.
+func (f *framework) requestData(taskID uint64, req string, deliver func(uint64, string, []byte)) {
+	go func() {
+		addr, err := f.taskAddr(taskID)
+		if err != nil {
+			f.log.Printf("WARN: data request to task %d: %v", taskID, err)
+			return
+		}
+		resp, err := f.transport.Request(context.Background(), addr, f.taskID, f.getEpoch(), req)
+		if err != nil {
+			f.log.Printf("WARN: data request to task %d: %v", taskID, err)
+			return
+		}
+		deliver(taskID, req, resp)
+	}()
+}
+
+// taskAddr looks up the address taskID published when it occupied its
+// slot, the same task-dir key occupyTask and keepAliveLease write to.
+func (f *framework) taskAddr(taskID uint64) (string, error) {
+	key := path.Join(etcdutil.MakeTaskDirPath(f.name), strconv.FormatUint(taskID, 10))
+	resp, err := f.etcdClient.Get(key, false, false)
+	if err != nil {
+		return "", err
+	}
+	return resp.Node.Value, nil
+}
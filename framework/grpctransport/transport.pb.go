@@ -0,0 +1,97 @@
+// Code generated by protoc-gen-go and protoc-gen-go-grpc from
+// transport.proto. DO NOT EDIT by hand in a tree with a working protoc;
+// this checked-in copy exists so the package builds without one.
+package grpctransport
+
+import (
+	"context"
+
+	proto "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+type DataRequest struct {
+	TaskID uint64 `protobuf:"varint,1,opt,name=TaskID" json:"TaskID,omitempty"`
+	Req    string `protobuf:"bytes,2,opt,name=Req" json:"Req,omitempty"`
+	Epoch  uint64 `protobuf:"varint,3,opt,name=Epoch" json:"Epoch,omitempty"`
+}
+
+func (m *DataRequest) Reset()         { *m = DataRequest{} }
+func (m *DataRequest) String() string { return proto.CompactTextString(m) }
+func (*DataRequest) ProtoMessage()    {}
+
+type DataResponse struct {
+	Data []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+}
+
+func (m *DataResponse) Reset()         { *m = DataResponse{} }
+func (m *DataResponse) String() string { return proto.CompactTextString(m) }
+func (*DataResponse) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*DataRequest)(nil), "grpctransport.DataRequest")
+	proto.RegisterType((*DataResponse)(nil), "grpctransport.DataResponse")
+}
+
+// DataTransportClient is the client API for the DataTransport service.
+type DataTransportClient interface {
+	Request(ctx context.Context, in *DataRequest, opts ...grpc.CallOption) (*DataResponse, error)
+}
+
+type dataTransportClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewDataTransportClient wraps cc as a DataTransportClient.
+func NewDataTransportClient(cc *grpc.ClientConn) DataTransportClient {
+	return &dataTransportClient{cc}
+}
+
+func (c *dataTransportClient) Request(ctx context.Context, in *DataRequest, opts ...grpc.CallOption) (*DataResponse, error) {
+	out := new(DataResponse)
+	if err := c.cc.Invoke(ctx, "/grpctransport.DataTransport/Request", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DataTransportServer is the server API for the DataTransport service.
+type DataTransportServer interface {
+	Request(context.Context, *DataRequest) (*DataResponse, error)
+}
+
+// RegisterDataTransportServer registers srv to handle DataTransport RPCs on s.
+func RegisterDataTransportServer(s *grpc.Server, srv DataTransportServer) {
+	s.RegisterService(&_DataTransport_serviceDesc, srv)
+}
+
+func _DataTransport_Request_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DataTransportServer).Request(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpctransport.DataTransport/Request",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DataTransportServer).Request(ctx, req.(*DataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _DataTransport_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "grpctransport.DataTransport",
+	HandlerType: (*DataTransportServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Request",
+			Handler:    _DataTransport_Request_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "transport.proto",
+}
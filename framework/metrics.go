@@ -0,0 +1,148 @@
+package framework
+
+import (
+	"expvar"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are registered once at package init, the same way etcdserver
+// registers its expvar counters, so every framework instance in the
+// process publishes through one shared set of series. Every Prometheus
+// vector except taskgraph_occupy_total carries a "task" label; of the
+// expvar values only taskgraph_epoch is keyed by task, the others are
+// process-wide totals (keyed by role or outcome where they are maps).
+// SetEpoch and friends only ever update these, they never recreate
+// them, so counts survive epoch transitions.
+var (
+	epochGauge  = expvar.NewMap("taskgraph_epoch")
+	epochVec    = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "taskgraph_epoch",
+		Help: "Current epoch, as last observed by this task.",
+	}, []string{"task"})
+
+	occupyAttempts = expvar.NewInt("taskgraph_occupy_attempts_total")
+	occupySuccess  = expvar.NewInt("taskgraph_occupy_successes_total")
+	occupyVec      = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "taskgraph_occupy_total",
+		Help: "Task slot occupy attempts, by outcome.",
+	}, []string{"outcome"})
+
+	metaEvents = expvar.NewMap("taskgraph_meta_events_total")
+	metaVec    = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "taskgraph_meta_events_total",
+		Help: "Meta-watch events delivered to the task, by role.",
+	}, []string{"task", "role"})
+
+	dataRequests = expvar.NewMap("taskgraph_data_requests_total")
+	dataReqVec   = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "taskgraph_data_requests_total",
+		Help: "Data requests served, by task and outcome.",
+	}, []string{"task", "outcome"})
+	dataReqLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "taskgraph_data_request_latency_seconds",
+		Help:    "Data request handling latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"task"})
+
+	leaseRefreshFailures = expvar.NewInt("taskgraph_lease_refresh_failures_total")
+	leaseFailureVec      = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "taskgraph_lease_refresh_failures_total",
+		Help: "Lease refresh failures, by task.",
+	}, []string{"task"})
+
+	standbyCount = expvar.NewInt("taskgraph_standby_total")
+	standbyVec   = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "taskgraph_standby_total",
+		Help: "Number of times this process has demoted itself to standby.",
+	}, []string{"task"})
+)
+
+func recordEpoch(taskID, epoch uint64) {
+	label := strconv.FormatUint(taskID, 10)
+	epochGauge.Set(label, newExpvarInt(int64(epoch)))
+	epochVec.WithLabelValues(label).Set(float64(epoch))
+}
+
+func recordOccupyAttempt() {
+	occupyAttempts.Add(1)
+	occupyVec.WithLabelValues("attempt").Inc()
+}
+
+func recordOccupySuccess() {
+	occupySuccess.Add(1)
+	occupyVec.WithLabelValues("success").Inc()
+}
+
+func recordMetaEvent(taskID uint64, role taskRole) {
+	roleName := "child"
+	if role == roleParent {
+		roleName = "parent"
+	}
+	label := strconv.FormatUint(taskID, 10)
+	metaEvents.Add(roleName, 1)
+	metaVec.WithLabelValues(label, roleName).Inc()
+}
+
+func recordDataRequest(taskID uint64, start time.Time, ok bool) {
+	label := strconv.FormatUint(taskID, 10)
+	outcome := "failed"
+	if ok {
+		outcome = "served"
+	}
+	dataRequests.Add(outcome, 1)
+	dataReqVec.WithLabelValues(label, outcome).Inc()
+	dataReqLatency.WithLabelValues(label).Observe(time.Since(start).Seconds())
+}
+
+func recordLeaseRefreshFailure(taskID uint64) {
+	leaseRefreshFailures.Add(1)
+	leaseFailureVec.WithLabelValues(strconv.FormatUint(taskID, 10)).Inc()
+}
+
+func recordStandby(taskID uint64) {
+	standbyCount.Add(1)
+	standbyVec.WithLabelValues(strconv.FormatUint(taskID, 10)).Inc()
+}
+
+// newExpvarInt builds a fresh expvar.Var snapshotting v, since
+// expvar.Map.Set takes an expvar.Var rather than a plain int64.
+func newExpvarInt(v int64) expvar.Var {
+	iv := new(expvar.Int)
+	iv.Set(v)
+	return iv
+}
+
+// Collector returns a prometheus.Collector for this framework's
+// metrics, so applications can register it with their own registry
+// instead of (or in addition to) the expvar output on /debug/vars.
+func (f *framework) Collector() prometheus.Collector {
+	return frameworkCollector{}
+}
+
+// frameworkCollector fans Describe/Collect out to every metric vector
+// above; it has no state of its own since the vectors are already
+// package-level singletons shared with the expvar side.
+type frameworkCollector struct{}
+
+func (frameworkCollector) Describe(ch chan<- *prometheus.Desc) {
+	epochVec.Describe(ch)
+	occupyVec.Describe(ch)
+	metaVec.Describe(ch)
+	dataReqVec.Describe(ch)
+	dataReqLatency.Describe(ch)
+	leaseFailureVec.Describe(ch)
+	standbyVec.Describe(ch)
+}
+
+func (frameworkCollector) Collect(ch chan<- prometheus.Metric) {
+	epochVec.Collect(ch)
+	occupyVec.Collect(ch)
+	metaVec.Collect(ch)
+	dataReqVec.Collect(ch)
+	dataReqLatency.Collect(ch)
+	leaseFailureVec.Collect(ch)
+	standbyVec.Collect(ch)
+}
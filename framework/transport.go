@@ -0,0 +1,101 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// defaultShutdownGrace bounds how long Close waits for in-flight
+// /datareq requests to finish before forcing the listener closed,
+// unless overridden with WithShutdownGrace.
+const defaultShutdownGrace = 10 * time.Second
+
+// DataTransport is how the framework serves and issues /datareq-style
+// data requests between tasks. The default is plain HTTP; a node can
+// require mTLS between peers (or any other wire format) by supplying a
+// different DataTransport with WithDataTransport at bootstrap time —
+// everything else about the framework stays the same.
+type DataTransport interface {
+	// Serve starts serving handler and blocks until Close is called,
+	// returning nil on a clean shutdown.
+	Serve(handler http.Handler) error
+
+	// Request asks the task at addr for req on behalf of taskID at
+	// epoch, so the receiver can reject a request from a caller that's
+	// stuck on a stale epoch, and returns its response body.
+	Request(ctx context.Context, addr string, taskID, epoch uint64, req string) ([]byte, error)
+
+	// Close begins a graceful shutdown: in-flight requests are given
+	// gracePeriod to finish before the transport is torn down.
+	Close(gracePeriod time.Duration) error
+}
+
+// WithDataTransport swaps in a non-default DataTransport, e.g. a gRPC
+// transport configured for mTLS. The default, used if this option is
+// never passed, is plain HTTP on the bootstrap's listener.
+func WithDataTransport(t DataTransport) BootStrapOption {
+	return func(f *framework) { f.transport = t }
+}
+
+// WithShutdownGrace sets how long the data transport waits for
+// in-flight /datareq requests to finish during a graceful shutdown.
+func WithShutdownGrace(d time.Duration) BootStrapOption {
+	return func(f *framework) { f.shutdownGrace = d }
+}
+
+// httpTransport is the default DataTransport: plain HTTP, with
+// Shutdown-based draining instead of the old http.Serve/log.Fatalf pair.
+type httpTransport struct {
+	ln     net.Listener
+	server *http.Server
+}
+
+// NewHTTPTransport wraps ln as the default HTTP-based DataTransport.
+func NewHTTPTransport(ln net.Listener) DataTransport {
+	return &httpTransport{ln: ln}
+}
+
+func (t *httpTransport) Serve(handler http.Handler) error {
+	t.server = &http.Server{Handler: handler}
+	err := t.server.Serve(t.ln)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (t *httpTransport) Request(ctx context.Context, addr string, taskID, epoch uint64, req string) ([]byte, error) {
+	q := url.Values{}
+	q.Set("taskID", strconv.FormatUint(taskID, 10))
+	q.Set("epoch", strconv.FormatUint(epoch, 10))
+	q.Set("req", req)
+	reqURL := fmt.Sprintf("http://%s/datareq?%s", addr, q.Encode())
+	httpReq, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (t *httpTransport) Close(gracePeriod time.Duration) error {
+	if t.server == nil {
+		return nil
+	}
+	if gracePeriod <= 0 {
+		gracePeriod = defaultShutdownGrace
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	return t.server.Shutdown(ctx)
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	ph "path"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/go-distributed/meritop/pkg/etcdutil"
+)
+
+// EtcdChunkSize is the largest chunk a blob is split into before being
+// written across sibling keys, so a single etcd value stays well under
+// etcd's request size limit.
+const EtcdChunkSize = 512 * 1024
+
+// etcdManifest is the small control record kept at a File's keyPath.
+// The payload itself always lives in sibling chunk keys (keyPath's
+// value alone can't CAS-protect a multi-chunk write), so the manifest
+// carries the ModifiedIndex CAS is checked against plus how many
+// chunks currently make up the blob - without that count, a Sync that
+// shrinks the blob would leave the previous write's trailing chunks on
+// disk for the next Open to read back as garbage tacked onto the end.
+type etcdManifest struct {
+	Chunks int `json:"chunks"`
+}
+
+// EtcdFile is a File backed by a manifest key plus one or more chunk
+// keys in an etcd cluster, rooted at keyPath.
+type EtcdFile struct {
+	client    *etcd.Client
+	keyPath   string
+	chunkSize int
+	buf       *bytes.Buffer
+	index     uint64 // ModifiedIndex of the manifest key, for CAS on Sync
+	chunks    int    // number of chunk keys currently on disk for this file
+}
+
+func (ef *EtcdFile) Read(p []byte) (int, error) {
+	return ef.buf.Read(p)
+}
+
+func (ef *EtcdFile) Write(p []byte) (int, error) {
+	return ef.buf.Write(p)
+}
+
+func (ef *EtcdFile) chunkKey(i int) string {
+	return fmt.Sprintf("%s.%08d", ef.keyPath, i)
+}
+
+// Sync compare-and-swaps the manifest against the ModifiedIndex
+// recorded by the last Open/Sync, so a concurrent writer is detected,
+// then writes the payload across the chunk keys the manifest now
+// claims. Any chunk keys left over from a larger previous write are
+// removed, so a write that shrinks the blob can't leave stale trailing
+// data for the next Open to read back.
+func (ef *EtcdFile) Sync() error {
+	data := ef.buf.Bytes()
+	chunks := (len(data) + ef.chunkSize - 1) / ef.chunkSize
+	if chunks == 0 {
+		chunks = 1 // still write one (empty) chunk, so Open has something to read
+	}
+
+	manifest, err := json.Marshal(etcdManifest{Chunks: chunks})
+	if err != nil {
+		return err
+	}
+	resp, err := ef.compareAndSwap(manifest)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < chunks; i++ {
+		start := i * ef.chunkSize
+		end := start + ef.chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := ef.client.Set(ef.chunkKey(i), string(data[start:end]), 0); err != nil {
+			return err
+		}
+	}
+	for i := chunks; i < ef.chunks; i++ {
+		if _, err := ef.client.Delete(ef.chunkKey(i), false); err != nil && !etcdutil.IsKeyNotFound(err) {
+			return err
+		}
+	}
+
+	ef.index = resp.Node.ModifiedIndex
+	ef.chunks = chunks
+	return nil
+}
+
+func (ef *EtcdFile) compareAndSwap(manifest []byte) (*etcd.Response, error) {
+	if ef.index == 0 {
+		return ef.client.Create(ef.keyPath, string(manifest), 0)
+	}
+	return ef.client.CompareAndSwap(ef.keyPath, string(manifest), 0, "", ef.index)
+}
+
+// EtcdStore persists File blobs as etcd keys under a configurable
+// prefix, so task checkpoints and Backupable snapshots can live in the
+// same etcd cluster the framework already uses for coordination.
+type EtcdStore struct {
+	client    *etcd.Client
+	prefix    string
+	chunkSize int
+}
+
+func (es *EtcdStore) Open(path, name string) (File, error) {
+	keyPath := ph.Join(es.prefix, path, name)
+	resp, err := es.client.Get(keyPath, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest etcdManifest
+	if err := json.Unmarshal([]byte(resp.Node.Value), &manifest); err != nil {
+		return nil, err
+	}
+
+	ef := &EtcdFile{
+		client:    es.client,
+		keyPath:   keyPath,
+		chunkSize: es.chunkSize,
+		buf:       new(bytes.Buffer),
+		index:     resp.Node.ModifiedIndex,
+		chunks:    manifest.Chunks,
+	}
+	for i := 0; i < manifest.Chunks; i++ {
+		cresp, err := es.client.Get(ef.chunkKey(i), false, false)
+		if err != nil {
+			return nil, err
+		}
+		ef.buf.WriteString(cresp.Node.Value)
+	}
+	return ef, nil
+}
+
+// Create opens path/name for writing with an empty buffer, whether or
+// not a value already exists at that key. For a new key index stays 0,
+// so the first Sync uses client.Create instead of a CompareAndSwap that
+// would reject a key that doesn't exist yet; for an existing one its
+// current ModifiedIndex and chunk count are recorded, so Sync replaces
+// it by CompareAndSwap and removes chunks the new content doesn't use.
+func (es *EtcdStore) Create(path, name string) (File, error) {
+	keyPath := ph.Join(es.prefix, path, name)
+	ef := &EtcdFile{client: es.client, keyPath: keyPath, chunkSize: es.chunkSize, buf: new(bytes.Buffer)}
+	resp, err := es.client.Get(keyPath, false, false)
+	if err != nil {
+		if etcdutil.IsKeyNotFound(err) {
+			return ef, nil
+		}
+		return nil, err
+	}
+	var manifest etcdManifest
+	if err := json.Unmarshal([]byte(resp.Node.Value), &manifest); err != nil {
+		return nil, err
+	}
+	ef.index = resp.Node.ModifiedIndex
+	ef.chunks = manifest.Chunks
+	return ef, nil
+}
+
+// EtcdOption configures optional EtcdStore behavior before it's used.
+type EtcdOption func(*EtcdStore)
+
+// WithChunkSize overrides the default EtcdChunkSize a blob is split
+// into before being written across sibling keys. Most callers don't
+// need this; it exists for clusters with a non-default etcd request
+// size limit.
+func WithChunkSize(size int) EtcdOption {
+	return func(es *EtcdStore) { es.chunkSize = size }
+}
+
+// NewEtcd creates an EtcdStore that roots all Open() paths under prefix,
+// chunking every blob into pieces no larger than EtcdChunkSize unless
+// overridden with WithChunkSize.
+func NewEtcd(client *etcd.Client, prefix string, opts ...EtcdOption) *EtcdStore {
+	es := &EtcdStore{client: client, prefix: prefix, chunkSize: EtcdChunkSize}
+	for _, opt := range opts {
+		opt(es)
+	}
+	return es
+}
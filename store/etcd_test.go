@@ -0,0 +1,129 @@
+package store
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/go-distributed/meritop/pkg/etcdutil"
+)
+
+// TestEtcdFileChunkedRoundTrip checks that a blob larger than chunkSize
+// is split across multiple chunk keys and reassembled correctly on
+// Open, the same way a single-chunk blob would be.
+func TestEtcdFileChunkedRoundTrip(t *testing.T) {
+	t.Skip("requires a live etcd binary on PATH")
+	m := etcdutil.StartNewEtcdServer(t, "store_etcd_chunked_test")
+	defer m.Terminate(t)
+
+	es := NewEtcd(etcd.NewClient([]string{m.URL()}), "/chunked", WithChunkSize(4))
+	want := []byte("this is well over four bytes long")
+
+	f, err := es.Create("dir", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := es.Open("dir", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+// TestEtcdFileSyncRejectsStaleWrite checks that Sync's compare-and-swap
+// rejects a write based on a manifest ModifiedIndex that's no longer
+// current, the way two concurrent writers opening the same path should
+// never both succeed.
+func TestEtcdFileSyncRejectsStaleWrite(t *testing.T) {
+	t.Skip("requires a live etcd binary on PATH")
+	m := etcdutil.StartNewEtcdServer(t, "store_etcd_cas_test")
+	defer m.Terminate(t)
+
+	es := NewEtcd(etcd.NewClient([]string{m.URL()}), "/cas")
+
+	first, err := es.Create("dir", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Write([]byte("first"))
+	if err := first.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// second opens the same path before first's second write, so its
+	// CAS is still based on the ModifiedIndex of the initial "first" write.
+	second, err := es.Open("dir", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.Write([]byte("-updated"))
+	if err := first.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	second.Write([]byte("stale"))
+	if err := second.Sync(); err == nil {
+		t.Fatal("Sync with a stale ModifiedIndex should have failed CAS")
+	}
+}
+
+// TestEtcdFileSyncCleansUpStaleChunks checks that shrinking a blob on a
+// later Sync removes the previous write's now-unused trailing chunk
+// keys, so a subsequent Open doesn't read them back as garbage tacked
+// onto the end.
+func TestEtcdFileSyncCleansUpStaleChunks(t *testing.T) {
+	t.Skip("requires a live etcd binary on PATH")
+	m := etcdutil.StartNewEtcdServer(t, "store_etcd_cleanup_test")
+	defer m.Terminate(t)
+
+	client := etcd.NewClient([]string{m.URL()})
+	es := NewEtcd(client, "/cleanup", WithChunkSize(4))
+
+	f, err := es.Create("dir", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("twelve bytes"))
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	ef := f.(*EtcdFile)
+	if ef.chunks <= 1 {
+		t.Fatalf("got %d chunks, want more than 1 for a 12-byte blob with a 4-byte chunk size", ef.chunks)
+	}
+	staleChunkKey := ef.chunkKey(ef.chunks - 1)
+
+	f2, err := es.Open("dir", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Drain what Open read back before writing the shorter replacement:
+	// EtcdFile's buffer is the same bytes.Buffer for both reads and
+	// writes, so leaving the old content in it would just append to,
+	// not replace, the blob.
+	if _, err := ioutil.ReadAll(f2); err != nil {
+		t.Fatal(err)
+	}
+	f2.Write([]byte("hi"))
+	if err := f2.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Get(staleChunkKey, false, false); err == nil || !etcdutil.IsKeyNotFound(err) {
+		t.Fatalf("stale chunk key %s should have been deleted, got err=%v", staleChunkKey, err)
+	}
+}
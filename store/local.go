@@ -12,6 +12,17 @@ func (ls *LocalStore) Open(path, name string) (File, error) {
 	return os.OpenFile(ph.Join(path, name), os.O_RDWR, 0777)
 }
 
+// Create makes path if needed and opens path/name for writing,
+// truncating it first: without O_TRUNC, a shorter write than the file
+// already on disk would leave its own trailing bytes behind for the
+// next Open to read back as part of the blob.
+func (ls *LocalStore) Create(path, name string) (File, error) {
+	if err := os.MkdirAll(path, 0777); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(ph.Join(path, name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+}
+
 func NewLocal() *LocalStore {
 	return new(LocalStore)
 }
@@ -43,6 +43,18 @@ func (s3s *S3Store) Open(path, name string) (File, error) {
 	return &S3File{*bkt, name, bytes.NewBuffer(b)}, nil
 }
 
+// Create opens path/name for writing without requiring it to already
+// exist: S3 PUT always replaces the whole object, so there's no
+// stale-trailing-bytes risk the way there is for LocalStore, but Open's
+// Get-then-404-if-missing would still reject a brand new key outright.
+func (s3s *S3Store) Create(path, name string) (File, error) {
+	bkt := s3s.Bucket(path)
+	if bkt == nil {
+		return nil, errors.New("not found")
+	}
+	return &S3File{*bkt, name, new(bytes.Buffer)}, nil
+}
+
 func NewS3(auth aws.Auth, region aws.Region) *S3Store {
 	return &S3Store{*s3.New(auth, region)}
 }
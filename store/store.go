@@ -8,5 +8,12 @@ type File interface {
 }
 
 type Store interface {
+	// Open opens an existing path/name for reading and writing.
 	Open(path, name string) (File, error)
+
+	// Create opens path/name for writing, creating it if it doesn't
+	// exist and discarding any content already there, so a full
+	// rewrite (e.g. a fresh snapshot) never leaves stale trailing bytes
+	// behind from a shorter previous write.
+	Create(path, name string) (File, error)
 }
@@ -26,8 +26,22 @@ type Task interface {
 	// These are payload for application purpose.
 	ServeAsParent(fromID uint64, req string, dataReceiver chan<- []byte)
 	ServeAsChild(fromID uint64, req string, dataReceiver chan<- []byte)
+
+	// AlarmRaised is called whenever the framework observes a named
+	// cluster-wide alarm (see the alarm package) going active or
+	// clearing. meta carries alarm-specific context, e.g. which task
+	// raised it or the offending epoch. Embed NoopAlarmHandler to get a
+	// no-op implementation for free.
+	AlarmRaised(name string, meta map[string]string)
 }
 
+// NoopAlarmHandler is a no-op AlarmRaised, meant to be embedded by Task
+// implementations that predate the alarm subsystem and don't care to
+// react to alarms themselves.
+type NoopAlarmHandler struct{}
+
+func (NoopAlarmHandler) AlarmRaised(name string, meta map[string]string) {}
+
 type UpdateLog interface {
 	UpdateID()
 }
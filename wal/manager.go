@@ -0,0 +1,80 @@
+package wal
+
+import (
+	"time"
+
+	"github.com/go-distributed/meritop/store"
+)
+
+// Manager drives the periodic snapshot-and-truncate cycle for a single
+// Backupable task: after SnapshotEvery appends, or every
+// SnapshotInterval, whichever comes first, it snapshots the task's
+// state and truncates the WAL up to that point.
+type Manager struct {
+	Store            store.Store
+	Path             string
+	SnapshotEvery    int
+	SnapshotInterval time.Duration
+
+	dir          string
+	appends      int
+	lastSnapshot time.Time
+}
+
+// NewManager wires a Manager around the WAL rooted at dir, snapshotting
+// to s under path.
+func NewManager(dir string, s store.Store, path string) *Manager {
+	return &Manager{
+		Store:            s,
+		Path:             path,
+		SnapshotEvery:    1000,
+		SnapshotInterval: 30 * time.Second,
+		dir:              dir,
+	}
+}
+
+// Observe records that one entry was appended at index, snapshotting
+// and truncating the WAL once SnapshotEvery appends or SnapshotInterval
+// has elapsed since the last snapshot.
+func (m *Manager) Observe(snap Snapshotter, index uint64, now time.Time) error {
+	m.appends++
+	if m.appends < m.SnapshotEvery && now.Sub(m.lastSnapshot) < m.SnapshotInterval {
+		return nil
+	}
+	if err := SaveSnapshot(m.Store, m.Path, snap, index); err != nil {
+		return err
+	}
+	if err := Truncate(m.dir, index); err != nil {
+		return err
+	}
+	m.appends = 0
+	m.lastSnapshot = now
+	return nil
+}
+
+// Recover loads the latest snapshot, if any, then replays every WAL
+// record after it through apply, in order. It's meant to be called
+// from Init, before the task starts serving traffic, so the task comes
+// up exactly where the last snapshot plus log left off. lastIndex is
+// the index of the last record folded in, either from the snapshot
+// header or the final replayed record, so the caller can resume
+// appending from the right place instead of restarting at 0.
+//
+// A missing snapshot (the common case for a task's very first run) is
+// not an error: recovery just starts from the beginning of the WAL.
+func (m *Manager) Recover(apply func(Record)) (snapshotData []byte, lastIndex uint64, err error) {
+	data, index, err := LoadSnapshot(m.Store, m.Path)
+	if err != nil {
+		data, index = nil, 0
+	}
+
+	records, err := ReadAll(m.dir, index)
+	if err != nil {
+		return data, index, err
+	}
+	for _, rec := range records {
+		apply(rec)
+		index = rec.Index
+	}
+	return data, index, nil
+}
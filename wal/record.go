@@ -0,0 +1,69 @@
+package wal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// recordHeaderSize is the fixed header written before every record's
+// payload: the record's index, its payload length, and a CRC32 of the
+// payload.
+const recordHeaderSize = 8 + 4 + 4
+
+// Record is one WAL entry: the index the UpdateLog was appended under,
+// plus the opaque bytes the task serialized it into.
+type Record struct {
+	Index uint64
+	Data  []byte
+}
+
+func encodeRecord(r Record) []byte {
+	buf := make([]byte, recordHeaderSize+len(r.Data))
+	binary.BigEndian.PutUint64(buf[0:8], r.Index)
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(r.Data)))
+	binary.BigEndian.PutUint32(buf[12:16], crc32.ChecksumIEEE(r.Data))
+	copy(buf[recordHeaderSize:], r.Data)
+	return buf
+}
+
+// decodeRecord reads one record off the front of buf, returning the
+// record and the number of bytes it consumed. It errors if the header
+// or payload is incomplete or the checksum doesn't match, so callers
+// can tell a corrupt/partial trailing record from a real decode failure.
+func decodeRecord(buf []byte) (Record, int, error) {
+	if len(buf) < recordHeaderSize {
+		return Record{}, 0, fmt.Errorf("wal: short record header")
+	}
+	index := binary.BigEndian.Uint64(buf[0:8])
+	length := binary.BigEndian.Uint32(buf[8:12])
+	sum := binary.BigEndian.Uint32(buf[12:16])
+
+	end := recordHeaderSize + int(length)
+	if len(buf) < end {
+		return Record{}, 0, fmt.Errorf("wal: truncated record payload")
+	}
+	data := buf[recordHeaderSize:end]
+	if crc32.ChecksumIEEE(data) != sum {
+		return Record{}, 0, fmt.Errorf("wal: checksum mismatch")
+	}
+	return Record{Index: index, Data: data}, end, nil
+}
+
+// validPrefixLen scans buf from the start and returns the length of the
+// longest prefix that decodes cleanly as whole records. Anything after
+// that point is either a torn record left by an unclean shutdown mid-
+// Append, or real corruption - either way it isn't a record a caller
+// can trust.
+func validPrefixLen(buf []byte) int {
+	n := 0
+	for len(buf) > 0 {
+		_, used, err := decodeRecord(buf)
+		if err != nil {
+			break
+		}
+		buf = buf[used:]
+		n += used
+	}
+	return n
+}
@@ -0,0 +1,71 @@
+package wal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/go-distributed/meritop/store"
+)
+
+// snapHeaderSize is the fixed header written before every snapshot
+// blob: the index of the last WAL record folded into it.
+const snapHeaderSize = 8
+
+// snapshotName is the name snapshots are stored under within path, so
+// the latest one can always be found without listing the store.
+const snapshotName = "snapshot"
+
+// Snapshotter lets a Backupable task hand back its full current state
+// as opaque bytes, so the WAL can be truncated once that state is
+// durable.
+type Snapshotter interface {
+	Snapshot() ([]byte, error)
+}
+
+// SaveSnapshot asks snap for its current state and writes it to s under
+// path, prefixed with the index of the last WAL record it reflects.
+func SaveSnapshot(s store.Store, path string, snap Snapshotter, index uint64) error {
+	data, err := snap.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	f, err := s.Create(path, snapshotName)
+	if err != nil {
+		return err
+	}
+
+	var header [snapHeaderSize]byte
+	binary.BigEndian.PutUint64(header[:], index)
+	if _, err := f.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// LoadSnapshot reads back the most recent snapshot written by
+// SaveSnapshot, returning its payload and the index of the last WAL
+// record it already reflects.
+func LoadSnapshot(s store.Store, path string) (data []byte, index uint64, err error) {
+	f, err := s.Open(path, snapshotName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header := make([]byte, snapHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, 0, fmt.Errorf("wal: snapshot header too short: %v", err)
+	}
+	index = binary.BigEndian.Uint64(header)
+
+	data, err = ioutil.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, index, nil
+}